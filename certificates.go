@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pborman/uuid"
+)
+
+// certificate is a trusted agent certificate, mirroring LXD's own trust
+// store: each LXD host's certificate must be enrolled here before the
+// scheduler will talk to it over TLS.
+type certificate struct {
+	ID          string `json:"id" db:"id"`
+	Name        string `json:"name" db:"name"`
+	Fingerprint string `json:"fingerprint" db:"fingerprint"`
+	Certificate string `json:"certificate" db:"certificate"`
+}
+
+func (c *certificate) insertCertificate(db *sqlx.DB) error {
+	query := `INSERT INTO certificates (id, name, fingerprint, certificate) VALUES ($1, $2, $3, $4)`
+	_, err := db.Exec(query, c.ID, c.Name, c.Fingerprint, c.Certificate)
+	return err
+}
+
+func getTrustedCertificates(db *sqlx.DB) ([]certificate, error) {
+	var certs []certificate
+	query := `SELECT id, name, fingerprint, certificate FROM certificates`
+	err := db.Select(&certs, query)
+	return certs, err
+}
+
+// loadAgentTLSConfig builds the tls.Config used to talk to LXD agents: the
+// scheduler's own certificate/key for mutual TLS, plus a trust pool made up
+// of every certificate enrolled via POST /api/v1/certificates.
+func loadAgentTLSConfig(certFile, keyFile string, db *sqlx.DB) (*tls.Config, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	trusted, err := getTrustedCertificates(db)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	for _, cert := range trusted {
+		pool.AppendCertsFromPEM([]byte(cert.Certificate))
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		RootCAs:      pool,
+	}, nil
+}
+
+// createCertificateHandler enrolls a new agent certificate into the trust
+// store so the scheduler will accept and present TLS connections to it.
+func (s *scheduler) createCertificateHandler(w http.ResponseWriter, r *http.Request) {
+	type createCertificateRequest struct {
+		Name        string `json:"name"`
+		Fingerprint string `json:"fingerprint"`
+		Certificate string `json:"certificate"`
+	}
+
+	var data createCertificateRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&data); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	cert := certificate{
+		ID:          uuid.New(),
+		Name:        data.Name,
+		Fingerprint: data.Fingerprint,
+		Certificate: data.Certificate,
+	}
+
+	if err := cert.insertCertificate(s.DB); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Newly enrolled agents must be trusted immediately, not after a
+	// restart: rebuild the agent client's tls.Config from the certificates
+	// table (now including this row) and swap it in.
+	s.reloadAgentTrust()
+
+	respondWithJSON(w, http.StatusOK, cert)
+}
@@ -0,0 +1,46 @@
+// Package events provides a WebSocket fan-out hub for scheduler and agent
+// lifecycle events, mirroring LXD's own events API.
+package events
+
+import "time"
+
+// Type categorizes an Event so listeners can filter the stream they care
+// about.
+type Type string
+
+const (
+	TypeLifecycle Type = "lifecycle"
+	TypeOperation Type = "operation"
+	TypeLogging   Type = "logging"
+)
+
+// Lifecycle actions emitted on container/VM and LXD health transitions.
+const (
+	ActionLxcCreated       = "lxc-created"
+	ActionLxcDeleted       = "lxc-deleted"
+	ActionStateChanged     = "state-changed"
+	ActionOperationUpdated = "operation-updated"
+	ActionLxdHealthChanged = "lxd-health-changed"
+)
+
+// Event is a single message broadcast to every matching listener.
+type Event struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Type      Type                   `json:"type"`
+	Metadata  map[string]interface{} `json:"metadata"`
+}
+
+// New builds an Event of the given type and action, stamped with the
+// current time.
+func New(typ Type, action string, metadata map[string]interface{}) Event {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["action"] = action
+
+	return Event{
+		Timestamp: time.Now(),
+		Type:      typ,
+		Metadata:  metadata,
+	}
+}
@@ -0,0 +1,142 @@
+package events
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// writeTimeout bounds how long a single listener's write can block Send,
+// so one stuck client can't stall the whole fan-out.
+const writeTimeout = 5 * time.Second
+
+// listener is a single connected WebSocket client and the event types it
+// subscribed to. gorilla/websocket forbids concurrent writes to the same
+// connection, and Send is called concurrently from many goroutines (agent
+// health checks, operation updates, saga completions), so every write goes
+// through writeMu.
+type listener struct {
+	conn  *websocket.Conn
+	types map[Type]bool
+
+	writeMu sync.Mutex
+}
+
+// send serializes a single write to the listener's connection.
+func (l *listener) send(event Event) error {
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+
+	l.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return l.conn.WriteJSON(event)
+}
+
+// Hub maintains the set of connected listeners and fans events out to the
+// ones subscribed to a matching type.
+type Hub struct {
+	mu        sync.RWMutex
+	listeners map[*listener]bool
+}
+
+// NewHub creates an empty Hub.
+func NewHub() *Hub {
+	return &Hub{listeners: make(map[*listener]bool)}
+}
+
+// Send broadcasts event to every listener subscribed to its type. Writes to
+// each listener's connection are serialized by the listener itself, so
+// concurrent Send calls never race on the same conn; only the set of
+// matching listeners is read under mu.
+func (h *Hub) Send(event Event) {
+	h.mu.RLock()
+	matching := make([]*listener, 0, len(h.listeners))
+	for l := range h.listeners {
+		if l.types[event.Type] {
+			matching = append(matching, l)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, l := range matching {
+		if err := l.send(event); err != nil {
+			log.Printf("events: dropping listener after write error: %v", err)
+			go h.remove(l)
+		}
+	}
+}
+
+// ServeWS upgrades r into a WebSocket connection and streams events whose
+// type matches the comma-separated `type` query parameter (defaulting to
+// all types) until the client disconnects.
+func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("events: upgrade failed: %v", err)
+		return
+	}
+
+	l := &listener{conn: conn, types: parseTypes(r.URL.Query().Get("type"))}
+
+	h.mu.Lock()
+	h.listeners[l] = true
+	h.mu.Unlock()
+
+	defer h.remove(l)
+
+	// Drain and discard reads so control frames are processed and we notice
+	// the client disconnecting.
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (h *Hub) remove(l *listener) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.listeners[l]; !ok {
+		return
+	}
+	delete(h.listeners, l)
+	l.conn.Close()
+}
+
+func parseTypes(raw string) map[Type]bool {
+	if raw == "" {
+		return map[Type]bool{TypeLifecycle: true, TypeOperation: true, TypeLogging: true}
+	}
+
+	types := make(map[Type]bool)
+	for _, part := range strings.Split(raw, ",") {
+		types[Type(strings.TrimSpace(part))] = true
+	}
+	return types
+}
+
+// StreamUpstream re-broadcasts every event read from conn (an already-
+// dialed agent event socket, e.g. from agentclient.Client.GetEvents) through
+// h, until the connection drops or errors. It takes ownership of conn and
+// always closes it before returning. Dialing is left to the caller so the
+// connection goes out over whatever transport (TLS or plain) the caller's
+// client is configured for, rather than hub.go hard-coding its own.
+func StreamUpstream(h *Hub, conn *websocket.Conn) error {
+	defer conn.Close()
+
+	for {
+		var event Event
+		if err := conn.ReadJSON(&event); err != nil {
+			return err
+		}
+		h.Send(event)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/pborman/uuid"
+
+	"github.com/vin0298/saga-scheduler/events"
+	"github.com/vin0298/saga-scheduler/operations"
+	"github.com/vin0298/saga-scheduler/saga"
+)
+
+// instanceType mirrors LXD's own container/VM split: both are driven
+// through the same create/delete/updatestate surface, but VMs are scheduled
+// and talked to a little differently under the hood.
+type instanceType string
+
+const (
+	instanceTypeContainer      instanceType = "container"
+	instanceTypeVirtualMachine instanceType = "virtual-machine"
+)
+
+// vm is the VM analogue of lxc, persisted in its own table so VM-specific
+// scheduling constraints (memory/CPU sizing, in-guest agent address) don't
+// have to be bolted onto the container row.
+type vm struct {
+	ID         string `json:"id" db:"id"`
+	LxdID      string `json:"lxd_id" db:"lxd_id"`
+	Name       string `json:"name" db:"name"`
+	Alias      string `json:"alias" db:"alias"`
+	IsDeployed int    `json:"is_deployed" db:"is_deployed"`
+}
+
+func (v *vm) insertVM(db *sqlx.DB) error {
+	query := `INSERT INTO vm (id, lxd_id, name, alias, is_deployed) VALUES ($1, $2, $3, $4, $5)`
+	_, err := db.Exec(query, v.ID, v.LxdID, v.Name, v.Alias, v.IsDeployed)
+	return err
+}
+
+func (v *vm) getVM(db *sqlx.DB) error {
+	query := `SELECT id, lxd_id, name, alias, is_deployed FROM vm WHERE id = $1`
+	return db.Get(v, query, v.ID)
+}
+
+func (v *vm) deleteVM(db *sqlx.DB) error {
+	query := `DELETE FROM vm WHERE id = $1`
+	_, err := db.Exec(query, v.ID)
+	return err
+}
+
+// getLowestLoadLxdInstanceForVM picks the LXD host with the fewest deployed
+// VMs. This is a count-based proxy for load, not actual memory/CPU
+// capacity: the lxd table doesn't track per-host resource usage, so unlike
+// metricsDB.getLowestLoadLxdInstance (used for containers), there's no
+// finer-grained signal to schedule VMs against yet.
+func getLowestLoadLxdInstanceForVM(db *sqlx.DB) (lxd, error) {
+	var candidate lxd
+	query := `SELECT l.id, l.name, l.address FROM lxd l
+		LEFT JOIN vm v ON v.lxd_id = l.id AND v.is_deployed = 1
+		GROUP BY l.id
+		ORDER BY count(v.id) ASC
+		LIMIT 1`
+	err := db.Get(&candidate, query)
+	return candidate, err
+}
+
+// createNewInstanceHandler is the unified entry point for both containers
+// and VMs, branching on data.InstanceType (not Type, which is the LXC image
+// source type and predates VM support). Containers keep using the existing
+// lxc saga; VMs are reserved against getLowestLoadLxdInstanceForVM and talk
+// to the agent's VM endpoints instead.
+func (s *scheduler) createNewInstanceHandler(w http.ResponseWriter, r *http.Request) {
+	var data createContainerRequestData
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&data); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if instanceType(data.InstanceType) != instanceTypeVirtualMachine {
+		s.createNewLxcWithData(w, r, data)
+		return
+	}
+
+	var lxdInstance lxd
+	newVM := vm{ID: uuid.New()}
+	var op *operation
+
+	asyncOp := s.operations.Create(operations.ClassTask)
+	opCtx, cancel := detachedOperationContext(r)
+
+	createSaga := saga.New(newVM.ID, "create-vm", []saga.Step{
+		{
+			Name: "reserve-lxd-slot",
+			Forward: func(ctx context.Context) error {
+				instance, err := getLowestLoadLxdInstanceForVM(s.DB)
+				if err != nil {
+					return err
+				}
+				lxdInstance = instance
+				return nil
+			},
+		},
+		{
+			Name: "insert-vm-row",
+			Forward: func(ctx context.Context) error {
+				newVM.LxdID = lxdInstance.ID
+				newVM.Name = data.Name
+				newVM.Alias = data.Alias
+				newVM.IsDeployed = 1
+				return newVM.insertVM(s.DB)
+			},
+			Compensate: func(ctx context.Context) error {
+				return newVM.deleteVM(s.DB)
+			},
+		},
+		{
+			Name: "call-agent",
+			Forward: func(ctx context.Context) error {
+				result, err := s.createNewVM(ctx, data, lxdInstance.Address)
+				if err != nil {
+					return err
+				}
+				op = result
+				asyncOp.SetCancel(func() error {
+					cancelCtx, cancel := context.WithTimeout(context.Background(), defaultAgentTimeout)
+					defer cancel()
+					return s.getAgent().CancelOperation(cancelCtx, lxdInstance.Address, op.ID)
+				})
+				return nil
+			},
+			Compensate: func(ctx context.Context) error {
+				return s.deleteNewVM(data.Name, lxdInstance.Address)
+			},
+		},
+		{
+			Name: "insert-operation",
+			Forward: func(ctx context.Context) error {
+				op.LxcID = newVM.ID
+				return op.insertOperation(s.DB)
+			},
+		},
+	}, s.DB)
+
+	s.operations.Run(opCtx, asyncOp, func(ctx context.Context) (map[string]interface{}, error) {
+		defer cancel()
+		if err := createSaga.Run(ctx); err != nil {
+			return nil, err
+		}
+		s.events.Send(events.New(events.TypeLifecycle, events.ActionLxcCreated, map[string]interface{}{"vm_id": newVM.ID}))
+		return map[string]interface{}{"vm_id": newVM.ID, "operation": op}, nil
+	})
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/operations/%s", asyncOp.ID))
+	respondWithJSON(w, http.StatusAccepted, asyncOp)
+}
+
+func (s *scheduler) createNewVM(ctx context.Context, data createContainerRequestData, lxdIPAddress string) (*operation, error) {
+	remote, err := s.getAgent().CreateVM(ctx, lxdIPAddress, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return toLocalOperation(remote)
+}
+
+// deleteNewVM is the compensating action for a VM whose agent call
+// succeeded but a later saga step failed. It always runs with its own
+// background context so the abort still goes out even if the saga's own
+// context has already been cancelled.
+func (s *scheduler) deleteNewVM(name, lxdIPAddress string) error {
+	abortCtx, abortCancel := context.WithTimeout(context.Background(), defaultAgentTimeout)
+	defer abortCancel()
+
+	_, err := s.getAgent().DeleteVM(abortCtx, lxdIPAddress, struct {
+		Name string `json:"name"`
+	}{Name: name})
+	return err
+}
+
+// instanceAgentHandler proxies exec/file operations through the in-guest
+// agent of a VM (or the equivalent container agent), so a caller can run
+// commands the same way regardless of instance type. It forwards the
+// request method, body and the remainder of the path after {id}/agent
+// straight through to the target's in-guest agent socket.
+func (s *scheduler) instanceAgentHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	target := vm{ID: id}
+	var lxdAddress string
+	if err := target.getVM(s.DB); err == nil {
+		lxdInstance := lxd{ID: target.LxdID}
+		if err := lxdInstance.getLxd(s.DB); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		lxdAddress = lxdInstance.Address
+	} else {
+		container := lxc{ID: id}
+		if err := container.getLxc(s.DB); err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		lxdInstance := lxd{ID: container.LxdID}
+		if err := lxdInstance.getLxd(s.DB); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		lxdAddress = lxdInstance.Address
+	}
+
+	suffix := mux.Vars(r)["agentPath"]
+	path := fmt.Sprintf("/api/v1/instance/%s/agent/%s", id, suffix)
+
+	response, err := s.getAgent().Raw(r.Context(), r.Method, lxdAddress, path, r.Body, r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	defer response.Body.Close()
+
+	w.WriteHeader(response.StatusCode)
+	io.Copy(w, response.Body)
+}
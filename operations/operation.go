@@ -0,0 +1,129 @@
+// Package operations models long-running agent work the way LXD itself
+// does: a client kicks off a task and gets back a handle it can poll, wait
+// on, or cancel, instead of blocking the original request for the duration
+// of the work.
+package operations
+
+import (
+	"sync"
+	"time"
+)
+
+// Class describes the kind of work an Operation represents.
+type Class string
+
+const (
+	ClassTask      Class = "task"
+	ClassWebsocket Class = "websocket"
+	ClassToken     Class = "token"
+)
+
+// Status is the lifecycle state of an Operation.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSuccess   Status = "success"
+	StatusFailure   Status = "failure"
+	StatusCancelled Status = "cancelled"
+)
+
+// Operation tracks a single unit of asynchronous work. Done is closed once
+// the operation reaches a terminal status (success, failure or cancelled).
+type Operation struct {
+	ID         string                 `json:"id"`
+	Class      Class                  `json:"class"`
+	Status     Status                 `json:"status"`
+	StatusCode int                    `json:"status_code"`
+	CreatedAt  time.Time              `json:"created_at"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+	Resources  map[string][]string    `json:"resources,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Err        string                 `json:"err,omitempty"`
+
+	mu     sync.Mutex
+	done   chan struct{}
+	cancel func() error
+}
+
+// newOperation creates a pending Operation of the given class.
+func newOperation(id string, class Class) *Operation {
+	now := time.Now()
+	return &Operation{
+		ID:        id,
+		Class:     class,
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+		done:      make(chan struct{}),
+	}
+}
+
+// Done returns a channel that is closed once the operation reaches a
+// terminal state.
+func (o *Operation) Done() <-chan struct{} {
+	return o.done
+}
+
+// SetCancel registers the hook invoked when a caller cancels the operation.
+func (o *Operation) SetCancel(cancel func() error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.cancel = cancel
+}
+
+// Cancel invokes the registered cancel hook, if any, and marks the
+// operation cancelled.
+func (o *Operation) Cancel() error {
+	o.mu.Lock()
+	cancel := o.cancel
+	o.mu.Unlock()
+
+	if cancel != nil {
+		if err := cancel(); err != nil {
+			return err
+		}
+	}
+
+	o.finish(StatusCancelled, 0, nil, "")
+	return nil
+}
+
+// setRunning transitions a pending operation to running.
+func (o *Operation) setRunning() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.Status = StatusRunning
+	o.UpdatedAt = time.Now()
+}
+
+// finish transitions the operation to a terminal status and closes Done.
+// It is a no-op if the operation has already finished.
+func (o *Operation) finish(status Status, statusCode int, metadata map[string]interface{}, errMsg string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	select {
+	case <-o.done:
+		return
+	default:
+	}
+
+	o.Status = status
+	o.StatusCode = statusCode
+	o.Metadata = metadata
+	o.Err = errMsg
+	o.UpdatedAt = time.Now()
+	close(o.done)
+}
+
+// isDone reports whether the operation has reached a terminal status.
+func (o *Operation) isDone() bool {
+	select {
+	case <-o.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,210 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pborman/uuid"
+
+	"github.com/vin0298/saga-scheduler/events"
+)
+
+// Registry tracks in-flight and completed operations in memory, keyed by
+// UUID. Operations are never removed from memory once created.
+type Registry struct {
+	mu         sync.RWMutex
+	operations map[string]*Operation
+	db         *sqlx.DB
+	hub        *events.Hub
+}
+
+// NewRegistry creates a Registry that persists terminal operations to the
+// operations table via db and, if hub is non-nil, broadcasts an
+// operation-updated event through hub every time an operation resolves or
+// fails.
+func NewRegistry(db *sqlx.DB, hub *events.Hub) *Registry {
+	return &Registry{
+		operations: make(map[string]*Operation),
+		db:         db,
+		hub:        hub,
+	}
+}
+
+// Create registers a new pending operation of the given class and returns
+// it. The caller is responsible for running the underlying work and
+// reporting its outcome via Run or Resolve/Fail.
+func (r *Registry) Create(class Class) *Operation {
+	op := newOperation(uuid.New(), class)
+
+	r.mu.Lock()
+	r.operations[op.ID] = op
+	r.mu.Unlock()
+
+	return op
+}
+
+// Run marks op running, then executes fn in a goroutine, resolving or
+// failing the operation depending on the error fn returns.
+func (r *Registry) Run(ctx context.Context, op *Operation, fn func(ctx context.Context) (map[string]interface{}, error)) {
+	op.setRunning()
+
+	go func() {
+		metadata, err := fn(ctx)
+		if err != nil {
+			r.Fail(op, err)
+			return
+		}
+		r.Resolve(op, metadata)
+	}()
+}
+
+// Resolve marks op successful with the given metadata and persists it.
+func (r *Registry) Resolve(op *Operation, metadata map[string]interface{}) {
+	op.finish(StatusSuccess, 200, metadata, "")
+	r.persist(op)
+	r.sendUpdated(op)
+}
+
+// Fail marks op failed with err and persists it.
+func (r *Registry) Fail(op *Operation, err error) {
+	op.finish(StatusFailure, 0, nil, err.Error())
+	r.persist(op)
+	r.sendUpdated(op)
+}
+
+// sendUpdated broadcasts an operation-updated event for op, if a hub was
+// configured.
+func (r *Registry) sendUpdated(op *Operation) {
+	if r.hub == nil {
+		return
+	}
+	r.hub.Send(events.New(events.TypeOperation, events.ActionOperationUpdated, map[string]interface{}{
+		"operation_id": op.ID,
+		"status":       string(op.Status),
+	}))
+}
+
+// Get returns the operation with the given id, checking the in-memory
+// registry first and falling back to the operations table for operations
+// from a previous process.
+func (r *Registry) Get(id string) (*Operation, error) {
+	r.mu.RLock()
+	op, ok := r.operations[id]
+	r.mu.RUnlock()
+	if ok {
+		return op, nil
+	}
+
+	return r.loadPersisted(id)
+}
+
+// List returns every operation currently tracked in memory.
+func (r *Registry) List() []*Operation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]*Operation, 0, len(r.operations))
+	for _, op := range r.operations {
+		result = append(result, op)
+	}
+	return result
+}
+
+// Cancel cancels the operation with the given id, invoking its registered
+// cancel hook, then persists and broadcasts the resulting status.
+func (r *Registry) Cancel(id string) error {
+	r.mu.RLock()
+	op, ok := r.operations[id]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("operation %s not found", id)
+	}
+
+	err := op.Cancel()
+	r.persist(op)
+	r.sendUpdated(op)
+	return err
+}
+
+// Wait blocks until the operation reaches a terminal state, timeout
+// elapses, or ctx is cancelled (e.g. the original client disconnected),
+// whichever comes first. It returns the operation either way so the caller
+// can inspect its current status. Wait is purely observational: a cancelled
+// ctx only means this caller stopped watching, not that the operation
+// itself should be cancelled, since other callers may still be waiting on
+// it. Callers that want to abort the operation must call Cancel
+// explicitly.
+func (r *Registry) Wait(ctx context.Context, id string, timeout time.Duration) (*Operation, error) {
+	op, err := r.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if op.isDone() {
+		return op, nil
+	}
+
+	select {
+	case <-op.Done():
+	case <-time.After(timeout):
+	case <-ctx.Done():
+	}
+
+	return op, nil
+}
+
+// persist writes a terminal operation to the operations table so it can be
+// retrieved after the process has restarted. It's a no-op if the Registry was built without a db, e.g. in
+// tests that only exercise the in-memory state machine.
+func (r *Registry) persist(op *Operation) {
+	if r.db == nil {
+		return
+	}
+
+	metadata, _ := json.Marshal(op.Metadata)
+
+	query := `INSERT INTO operations (id, class, status, status_code, metadata, err, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE SET status = $3, status_code = $4, metadata = $5, err = $6, updated_at = $8`
+
+	r.db.Exec(query, op.ID, op.Class, op.Status, op.StatusCode, metadata, op.Err, op.CreatedAt, op.UpdatedAt)
+}
+
+// loadPersisted reads a terminal operation back from the operations table.
+func (r *Registry) loadPersisted(id string) (*Operation, error) {
+	type row struct {
+		ID         string    `db:"id"`
+		Class      Class     `db:"class"`
+		Status     Status    `db:"status"`
+		StatusCode int       `db:"status_code"`
+		Metadata   []byte    `db:"metadata"`
+		Err        string    `db:"err"`
+		CreatedAt  time.Time `db:"created_at"`
+		UpdatedAt  time.Time `db:"updated_at"`
+	}
+
+	var result row
+	query := `SELECT id, class, status, status_code, metadata, err, created_at, updated_at FROM operations WHERE id = $1`
+	if err := r.db.Get(&result, query, id); err != nil {
+		return nil, err
+	}
+
+	op := &Operation{
+		ID:         result.ID,
+		Class:      result.Class,
+		Status:     result.Status,
+		StatusCode: result.StatusCode,
+		Err:        result.Err,
+		CreatedAt:  result.CreatedAt,
+		UpdatedAt:  result.UpdatedAt,
+		done:       make(chan struct{}),
+	}
+	close(op.done)
+	json.Unmarshal(result.Metadata, &op.Metadata)
+
+	return op, nil
+}
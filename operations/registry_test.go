@@ -0,0 +1,135 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistryRunResolvesOnSuccess(t *testing.T) {
+	r := NewRegistry(nil, nil)
+	op := r.Create(ClassTask)
+
+	r.Run(context.Background(), op, func(ctx context.Context) (map[string]interface{}, error) {
+		return map[string]interface{}{"ok": true}, nil
+	})
+
+	select {
+	case <-op.Done():
+	case <-time.After(time.Second):
+		t.Fatal("operation never finished")
+	}
+
+	if op.Status != StatusSuccess {
+		t.Errorf("Status = %q, want %q", op.Status, StatusSuccess)
+	}
+}
+
+func TestRegistryRunFailsOnError(t *testing.T) {
+	r := NewRegistry(nil, nil)
+	op := r.Create(ClassTask)
+	wantErr := errors.New("boom")
+
+	r.Run(context.Background(), op, func(ctx context.Context) (map[string]interface{}, error) {
+		return nil, wantErr
+	})
+
+	select {
+	case <-op.Done():
+	case <-time.After(time.Second):
+		t.Fatal("operation never finished")
+	}
+
+	if op.Status != StatusFailure {
+		t.Errorf("Status = %q, want %q", op.Status, StatusFailure)
+	}
+	if op.Err != wantErr.Error() {
+		t.Errorf("Err = %q, want %q", op.Err, wantErr.Error())
+	}
+}
+
+func TestRegistryWaitReturnsOnceOperationFinishes(t *testing.T) {
+	r := NewRegistry(nil, nil)
+	op := r.Create(ClassTask)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		r.Resolve(op, nil)
+	}()
+
+	result, err := r.Wait(context.Background(), op.ID, time.Second)
+	if err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+	if result.Status != StatusSuccess {
+		t.Errorf("Status = %q, want %q", result.Status, StatusSuccess)
+	}
+}
+
+func TestRegistryWaitTimesOutWithoutFinishing(t *testing.T) {
+	r := NewRegistry(nil, nil)
+	op := r.Create(ClassTask)
+
+	result, err := r.Wait(context.Background(), op.ID, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+	if result.isDone() {
+		t.Error("operation should still be pending after Wait times out")
+	}
+}
+
+func TestRegistryWaitDoesNotCancelOnContextDone(t *testing.T) {
+	r := NewRegistry(nil, nil)
+	op := r.Create(ClassTask)
+
+	cancelled := false
+	op.SetCancel(func() error {
+		cancelled = true
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := r.Wait(ctx, op.ID, time.Second)
+	if err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+	if cancelled {
+		t.Error("Wait must not invoke the cancel hook when the caller's context is done")
+	}
+	if result.isDone() {
+		t.Error("a disconnected waiter must not mark the operation done")
+	}
+}
+
+func TestRegistryCancelInvokesHookAndMarksCancelled(t *testing.T) {
+	r := NewRegistry(nil, nil)
+	op := r.Create(ClassTask)
+
+	invoked := false
+	op.SetCancel(func() error {
+		invoked = true
+		return nil
+	})
+
+	if err := r.Cancel(op.ID); err != nil {
+		t.Fatalf("Cancel returned error: %v", err)
+	}
+	if !invoked {
+		t.Error("Cancel did not invoke the registered cancel hook")
+	}
+	if op.Status != StatusCancelled {
+		t.Errorf("Status = %q, want %q", op.Status, StatusCancelled)
+	}
+}
+
+func TestRegistryCancelUnknownOperation(t *testing.T) {
+	r := NewRegistry(nil, nil)
+
+	if err := r.Cancel("does-not-exist"); err == nil {
+		t.Fatal("Cancel returned nil error for an unknown operation id")
+	}
+}
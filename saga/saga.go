@@ -0,0 +1,144 @@
+// Package saga implements the saga pattern: a sequence of forward steps that
+// can each be undone by a matching compensating action. If any forward step
+// fails, the already-executed steps are compensated in reverse order so the
+// overall workflow never leaves partial state behind.
+package saga
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Status represents the lifecycle state of a Saga.
+type Status string
+
+const (
+	StatusRunning      Status = "running"
+	StatusCommitted    Status = "committed"
+	StatusCompensating Status = "compensating"
+	StatusFailed       Status = "failed"
+)
+
+// Step is a single unit of work within a Saga. Forward performs the step;
+// Compensate undoes it. Compensate is only invoked for steps whose Forward
+// has already succeeded.
+type Step struct {
+	Name       string
+	Forward    func(ctx context.Context) error
+	Compensate func(ctx context.Context) error
+}
+
+// Saga is an ordered list of Steps executed as a single logical transaction.
+type Saga struct {
+	ID     string
+	Name   string
+	Steps  []Step
+	Status Status
+
+	db       *sqlx.DB
+	executed []int
+}
+
+// New creates a Saga with the given id and steps. If db is non-nil, Run
+// persists the saga's progress to the sagas table as it executes, so a
+// crashed scheduler can resume compensation for it on restart via
+// LoadIncomplete and Resume.
+func New(id, name string, steps []Step, db *sqlx.DB) *Saga {
+	return &Saga{
+		ID:     id,
+		Name:   name,
+		Steps:  steps,
+		Status: StatusRunning,
+		db:     db,
+	}
+}
+
+// Resume rebuilds a Saga previously persisted as record, for driving
+// recovery after a restart. started marks the steps (by name) that had
+// already executed at the time record was last persisted, so only those
+// are compensated; it's typically obtained from Record.StartedSteps. db is
+// used to persist the outcome of recovery back to the sagas table.
+func Resume(record Record, steps []Step, started map[string]bool, db *sqlx.DB) *Saga {
+	s := &Saga{
+		ID:     record.ID,
+		Name:   record.Name,
+		Steps:  steps,
+		Status: record.Status,
+		db:     db,
+	}
+	for i, step := range steps {
+		if started[step.Name] {
+			s.executed = append(s.executed, i)
+		}
+	}
+	return s
+}
+
+// Run executes the forward steps in order. If a step fails, Run compensates
+// every already-executed step in reverse order and returns the original
+// forward error.
+func (s *Saga) Run(ctx context.Context) error {
+	s.Status = StatusRunning
+
+	for i, step := range s.Steps {
+		if err := step.Forward(ctx); err != nil {
+			log.Printf("saga %s (%s): step %q failed: %v", s.ID, s.Name, step.Name, err)
+			// step itself is not marked executed: its Forward didn't
+			// succeed, so it must not be compensated along with the steps
+			// that came before it.
+			s.persist()
+			s.compensate(ctx)
+			s.Status = StatusFailed
+			s.persist()
+			return fmt.Errorf("saga %s: step %q failed: %w", s.Name, step.Name, err)
+		}
+		s.executed = append(s.executed, i)
+		s.persist()
+	}
+
+	s.Status = StatusCommitted
+	s.persist()
+	return nil
+}
+
+// compensate runs the Compensate func of every executed step in reverse
+// order. Compensation is best-effort: a failure is logged but does not stop
+// the remaining compensations from running.
+func (s *Saga) compensate(ctx context.Context) {
+	s.Status = StatusCompensating
+	s.persist()
+
+	for i := len(s.executed) - 1; i >= 0; i-- {
+		step := s.Steps[s.executed[i]]
+		if step.Compensate == nil {
+			continue
+		}
+		if err := step.Compensate(ctx); err != nil {
+			log.Printf("saga %s (%s): compensation for step %q failed: %v", s.ID, s.Name, step.Name, err)
+			continue
+		}
+		log.Printf("saga %s (%s): compensated step %q", s.ID, s.Name, step.Name)
+	}
+}
+
+// Compensate exposes compensate for callers driving recovery via Resume,
+// where there's no forward Run in progress to trigger it.
+func (s *Saga) Compensate(ctx context.Context) {
+	s.compensate(ctx)
+	s.Status = StatusFailed
+	s.persist()
+}
+
+// persist best-effort saves the saga's current progress via Persist. It's a
+// no-op if the saga was created with a nil db.
+func (s *Saga) persist() {
+	if s.db == nil {
+		return
+	}
+	if err := s.Persist(s.db); err != nil {
+		log.Printf("saga %s (%s): failed to persist progress: %v", s.ID, s.Name, err)
+	}
+}
@@ -0,0 +1,87 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRunCommitsWhenAllStepsSucceed(t *testing.T) {
+	var ran []string
+	s := New("id-1", "test-saga", []Step{
+		{Name: "a", Forward: func(ctx context.Context) error { ran = append(ran, "a"); return nil }},
+		{Name: "b", Forward: func(ctx context.Context) error { ran = append(ran, "b"); return nil }},
+	}, nil)
+
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if s.Status != StatusCommitted {
+		t.Errorf("Status = %q, want %q", s.Status, StatusCommitted)
+	}
+	if !reflect.DeepEqual(ran, []string{"a", "b"}) {
+		t.Errorf("forward order = %v, want [a b]", ran)
+	}
+}
+
+func TestRunCompensatesExecutedStepsInReverseOrder(t *testing.T) {
+	var compensated []string
+	wantErr := errors.New("boom")
+
+	s := New("id-2", "test-saga", []Step{
+		{
+			Name:       "a",
+			Forward:    func(ctx context.Context) error { return nil },
+			Compensate: func(ctx context.Context) error { compensated = append(compensated, "a"); return nil },
+		},
+		{
+			Name:       "b",
+			Forward:    func(ctx context.Context) error { return nil },
+			Compensate: func(ctx context.Context) error { compensated = append(compensated, "b"); return nil },
+		},
+		{
+			Name:       "c",
+			Forward:    func(ctx context.Context) error { return wantErr },
+			Compensate: func(ctx context.Context) error { compensated = append(compensated, "c"); return nil },
+		},
+	}, nil)
+
+	err := s.Run(context.Background())
+	if err == nil {
+		t.Fatal("Run returned nil error, want the forward failure wrapped")
+	}
+	if !errors.Is(err, wantErr) {
+		t.Errorf("Run error = %v, want it to wrap %v", err, wantErr)
+	}
+	if s.Status != StatusFailed {
+		t.Errorf("Status = %q, want %q", s.Status, StatusFailed)
+	}
+
+	// Step "c" is the one whose Forward failed, so it must not be
+	// compensated; only "a" and "b" ran, and compensation undoes them in
+	// reverse order.
+	if !reflect.DeepEqual(compensated, []string{"b", "a"}) {
+		t.Errorf("compensated order = %v, want [b a]", compensated)
+	}
+}
+
+func TestRunSkipsStepsWithoutCompensate(t *testing.T) {
+	var compensated []string
+
+	s := New("id-3", "test-saga", []Step{
+		{Name: "no-compensate", Forward: func(ctx context.Context) error { return nil }},
+		{
+			Name:       "fails",
+			Forward:    func(ctx context.Context) error { return errors.New("boom") },
+			Compensate: func(ctx context.Context) error { compensated = append(compensated, "fails"); return nil },
+		},
+	}, nil)
+
+	if err := s.Run(context.Background()); err == nil {
+		t.Fatal("Run returned nil error, want a failure")
+	}
+	if len(compensated) != 0 {
+		t.Errorf("compensated = %v, want none (failing step must not be compensated, prior step has no Compensate)", compensated)
+	}
+}
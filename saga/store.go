@@ -0,0 +1,81 @@
+package saga
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Record is the persisted representation of a Saga, stored in the sagas
+// table so an in-flight saga can be resumed after a scheduler restart.
+type Record struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Status    Status    `json:"status" db:"status"`
+	Steps     []byte    `json:"-" db:"steps"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// stepRecord is the JSONB representation of a single step's name and
+// whether it had already executed at the time of persistence.
+type stepRecord struct {
+	Name    string `json:"name"`
+	Started bool   `json:"started"`
+}
+
+// Persist inserts or updates the saga's row in the sagas table.
+func (s *Saga) Persist(db *sqlx.DB) error {
+	steps := make([]stepRecord, len(s.Steps))
+	executed := make(map[int]bool, len(s.executed))
+	for _, i := range s.executed {
+		executed[i] = true
+	}
+	for i, step := range s.Steps {
+		steps[i] = stepRecord{Name: step.Name, Started: executed[i]}
+	}
+
+	payload, err := json.Marshal(steps)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO sagas (id, name, status, steps, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, now(), now())
+		ON CONFLICT (id) DO UPDATE SET status = $3, steps = $4, updated_at = now()`
+
+	_, err = db.Exec(query, s.ID, s.Name, s.Status, payload)
+	return err
+}
+
+// StartedSteps decodes which of the saga's step names had already executed
+// successfully as of the last time the record was persisted, for use with
+// Resume. A step whose Forward was still in progress or had failed is not
+// included.
+func (r Record) StartedSteps() (map[string]bool, error) {
+	var steps []stepRecord
+	if err := json.Unmarshal(r.Steps, &steps); err != nil {
+		return nil, err
+	}
+
+	started := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		if step.Started {
+			started[step.Name] = true
+		}
+	}
+	return started, nil
+}
+
+// LoadIncomplete returns every saga row that was left in a non-terminal
+// state (running or compensating) by a previous scheduler process.
+func LoadIncomplete(db *sqlx.DB) ([]Record, error) {
+	var records []Record
+	query := `SELECT id, name, status, steps, created_at, updated_at FROM sagas WHERE status IN ($1, $2)`
+	err := db.Select(&records, query, StatusRunning, StatusCompensating)
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
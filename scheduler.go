@@ -1,66 +1,89 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"github.com/pborman/uuid"
+
+	"github.com/vin0298/saga-scheduler/agentclient"
+	"github.com/vin0298/saga-scheduler/events"
+	"github.com/vin0298/saga-scheduler/operations"
+	"github.com/vin0298/saga-scheduler/saga"
 )
 
 type scheduler struct {
-	Router    *mux.Router
-	DB        *sqlx.DB
-	client    client
-	metricsDB metricsDB
+	Router     *mux.Router
+	DB         *sqlx.DB
+	metricsDB  metricsDB
+	operations *operations.Registry
+	events     *events.Hub
+
+	agentMu sync.RWMutex
+	agent   *agentclient.Client
 }
 
-type createContainerRequestData struct {
-	Name     string `json:"name,omitempty"`
-	Type     string `json:"type,omitempty"`
-	Protocol string `json:"protocol,omitempty"`
-	Server   string `json:"server,omitempty"`
-	Alias    string `json:"alias,omitempty"`
+// getAgent returns the current agent client, safe for concurrent use with
+// reloadAgentTrust replacing it after a new certificate is enrolled.
+func (s *scheduler) getAgent() *agentclient.Client {
+	s.agentMu.RLock()
+	defer s.agentMu.RUnlock()
+	return s.agent
 }
 
-type client interface {
-	executeOperationRequest(req *http.Request) (*operation, error)
-}
+// reloadAgentTrust rebuilds the agent client's tls.Config from the current
+// contents of the certificates table, so a newly enrolled agent certificate
+// is trusted without restarting the scheduler.
+func (s *scheduler) reloadAgentTrust() {
+	agent := agentclient.New(s.loadAgentTLSConfig())
 
-type agentClient struct{}
+	s.agentMu.Lock()
+	s.agent = agent
+	s.agentMu.Unlock()
+}
 
-func (a agentClient) executeOperationRequest(req *http.Request) (*operation, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-	response, err := client.Do(req)
+// toLocalOperation re-encodes an agentclient.Operation into the scheduler's
+// own operation type. The two are structurally compatible (same JSON
+// shape), so round-tripping through JSON avoids duplicating field-by-field
+// conversion every time a handler talks to the agent.
+func toLocalOperation(remote *agentclient.Operation) (*operation, error) {
+	raw, err := json.Marshal(remote)
 	if err != nil {
 		return nil, err
 	}
 
-	defer response.Body.Close()
-
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return nil, err
-	}
 	var op *operation
-
-	err = json.Unmarshal(body, &op)
-	if err != nil {
+	if err := json.Unmarshal(raw, &op); err != nil {
 		return nil, err
 	}
 
 	return op, nil
 }
 
+// createContainerRequestData is the body for both POST /api/v1/container and
+// POST /api/v1/instance. Type is the LXC image source type (e.g. "image"),
+// already in use before VM support existed; the container/VM discriminator
+// therefore can't reuse that field and is carried separately in
+// InstanceType ("container", the default, or "virtual-machine").
+type createContainerRequestData struct {
+	Name         string `json:"name,omitempty"`
+	Type         string `json:"type,omitempty"`
+	Protocol     string `json:"protocol,omitempty"`
+	Server       string `json:"server,omitempty"`
+	Alias        string `json:"alias,omitempty"`
+	InstanceType string `json:"instance_type,omitempty"`
+}
+
 func (s *scheduler) initialize(user, password, dbname, host, port, sslmode string) error {
 	connectionString := fmt.Sprintf("user=%s password=%s dbname=%s host=%s port=%s sslmode=%s", user, password, dbname, host, port, sslmode)
 	var err error
@@ -74,12 +97,267 @@ func (s *scheduler) initialize(user, password, dbname, host, port, sslmode strin
 	s.Router.HandleFunc("/api/v1/container", s.getContainerHandler).Methods("GET")
 	s.Router.HandleFunc("/api/v1/container/updatestate", s.updateStateLxcHandler).Methods("POST")
 	s.Router.HandleFunc("/api/v1/container", s.deleteLxcHandler).Methods("DELETE")
-	s.client = agentClient{}
+	s.Router.HandleFunc("/api/v1/operations", s.listOperationsHandler).Methods("GET")
+	s.Router.HandleFunc("/api/v1/operations/{id}/wait", s.waitOperationHandler).Methods("GET")
+	s.Router.HandleFunc("/api/v1/operations/{id}", s.cancelOperationHandler).Methods("DELETE")
+	s.Router.HandleFunc("/api/v1/events", s.eventsHandler).Methods("GET")
+	s.Router.HandleFunc("/api/v1/instance", s.createNewInstanceHandler).Methods("POST")
+	s.Router.HandleFunc("/api/v1/instance/{id}/agent/{agentPath:.*}", s.instanceAgentHandler)
+	s.Router.HandleFunc("/api/v1/certificates", s.createCertificateHandler).Methods("POST")
+	s.reloadAgentTrust()
 	s.metricsDB = prometheusMetricsDB{}
+	s.events = events.NewHub()
+	s.operations = operations.NewRegistry(s.DB, s.events)
+
+	if err := s.recoverSagas(); err != nil {
+		return err
+	}
+
+	s.watchAgentEvents()
+
+	return nil
+}
+
+func (s *scheduler) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	s.events.ServeWS(w, r)
+}
+
+// watchAgentEvents connects an upstream event listener to every known LXD
+// host so agent-side events (e.g. a container actually finishing startup)
+// are re-broadcast through the scheduler's own event stream, rather than
+// the scheduler only trusting the initial 202 it got back from the agent.
+// Losing and regaining that connection is also the scheduler's signal for
+// an LXD host's own health, so each reconnect attempt emits an
+// ActionLxdHealthChanged event whenever the host's reachability flips.
+func (s *scheduler) watchAgentEvents() {
+	var addresses []string
+	if err := s.DB.Select(&addresses, `SELECT address FROM lxd`); err != nil {
+		log.Printf("watchAgentEvents: failed to list lxd hosts: %v", err)
+		return
+	}
+
+	for _, address := range addresses {
+		go s.watchAgentHealth(address)
+	}
+}
+
+// watchAgentHealth keeps reconnecting to a single LXD host's event socket
+// over the same TLS-aware transport as every other agent call, emitting an
+// ActionLxdHealthChanged event whenever the host transitions between
+// reachable and unreachable.
+func (s *scheduler) watchAgentHealth(address string) {
+	healthy := true
+	for {
+		connected := false
+		err := s.connectAgentEvents(address, func() {
+			connected = true
+			if !healthy {
+				healthy = true
+				s.events.Send(events.New(events.TypeLifecycle, events.ActionLxdHealthChanged, map[string]interface{}{"address": address, "healthy": true}))
+			}
+		})
+
+		if !connected && healthy {
+			healthy = false
+			s.events.Send(events.New(events.TypeLifecycle, events.ActionLxdHealthChanged, map[string]interface{}{"address": address, "healthy": false}))
+			log.Printf("watchAgentEvents: %v", err)
+		}
+
+		time.Sleep(defaultAgentTimeout)
+	}
+}
+
+// connectAgentEvents dials the agent's event socket via the scheduler's
+// TLS-aware agent client, calls onConnected once the dial succeeds, and
+// streams it into s.events until the connection drops or errors.
+func (s *scheduler) connectAgentEvents(address string, onConnected func()) error {
+	conn, err := s.getAgent().GetEvents(address)
+	if err != nil {
+		return fmt.Errorf("connecting to agent event socket at %s: %w", address, err)
+	}
+	onConnected()
+
+	return events.StreamUpstream(s.events, conn)
+}
+
+const (
+	schedulerCertFile = "/etc/saga-scheduler/scheduler.crt"
+	schedulerKeyFile  = "/etc/saga-scheduler/scheduler.key"
+
+	// defaultAgentTimeout bounds how long a synchronous handler will wait on
+	// an agent call when the caller doesn't specify its own timeout. It is
+	// not applied to detached (async) operations: those can legitimately run
+	// far longer than any reasonable default, so they stay unbounded unless
+	// the caller asks for a deadline explicitly.
+	defaultAgentTimeout = 10 * time.Second
+)
+
+// requestDeadline reads the caller's requested agent-call deadline from the
+// X-Timeout header or a `timeout` query parameter (both in seconds). ok is
+// false if the caller didn't specify one.
+func requestDeadline(r *http.Request) (timeout time.Duration, ok bool) {
+	raw := r.Header.Get("X-Timeout")
+	if raw == "" {
+		raw = r.URL.Query().Get("timeout")
+	}
+	if raw == "" {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// operationContext derives a context for an agent call that runs
+// synchronously within the handler: it inherits r.Context() (so a client
+// disconnect cancels the call) and applies the caller's requested deadline,
+// or defaultAgentTimeout if none was given. The caller must invoke the
+// returned cancel func.
+func operationContext(r *http.Request) (context.Context, context.CancelFunc) {
+	timeout := defaultAgentTimeout
+	if t, ok := requestDeadline(r); ok {
+		timeout = t
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// detachedOperationContext derives a context for an agent call that keeps
+// running in a background goroutine after the handler has already
+// returned a 202 Accepted. It must NOT be derived from r.Context(): net/http
+// cancels that the instant the handler function returns, which would abort
+// the in-flight agent call before it ever completes. Unlike
+// operationContext, it has no default deadline — only a caller-specified
+// one — since detached work is expected to run well past typical request
+// timeouts. A caller that wants to stop a detached operation early should
+// cancel it through the operations.Registry instead of relying on a
+// deadline.
+func detachedOperationContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if timeout, ok := requestDeadline(r); ok {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// loadAgentTLSConfig loads the scheduler's certificate/key and the agent
+// trust store from the certificates table into a tls.Config for
+// agentclient.New. If the scheduler certificate/key are not present, it
+// returns nil and agentclient falls back to a plain http.Transport so
+// existing deployments keep working until they're enrolled.
+func (s *scheduler) loadAgentTLSConfig() *tls.Config {
+	config, err := loadAgentTLSConfig(schedulerCertFile, schedulerKeyFile, s.DB)
+	if err != nil {
+		log.Printf("agent TLS disabled: %v", err)
+		return nil
+	}
+	return config
+}
+
+// recoverSagas resumes sagas that were left running or compensating by a
+// previous scheduler process, e.g. one that crashed mid-workflow. Since the
+// sagas table only records which steps had completed, recovery always
+// re-runs compensation for the recorded steps rather than attempting to
+// resume forward progress.
+func (s *scheduler) recoverSagas() error {
+	records, err := saga.LoadIncomplete(s.DB)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		log.Printf("recovering saga %s (%s) left in status %s", record.ID, record.Name, record.Status)
+
+		started, err := record.StartedSteps()
+		if err != nil {
+			log.Printf("recovering saga %s (%s): failed to decode recorded steps: %v", record.ID, record.Name, err)
+			continue
+		}
+
+		var steps []saga.Step
+		switch record.Name {
+		case "create-lxc":
+			steps = s.recoverCreateLxcSteps(record.ID)
+		case "create-vm":
+			steps = s.recoverCreateVMSteps(record.ID)
+		default:
+			log.Printf("recovering saga %s (%s): no recovery steps defined for this saga, leaving as-is", record.ID, record.Name)
+			continue
+		}
+
+		saga.Resume(record, steps, started, s.DB).Compensate(context.Background())
+	}
 
 	return nil
 }
 
+// recoverCreateLxcSteps rebuilds the compensating actions for a "create-lxc"
+// saga left incomplete by a previous process, looking up whatever the
+// lxc/lxd rows can still tell us about the resource that was being created.
+func (s *scheduler) recoverCreateLxcSteps(id string) []saga.Step {
+	target := lxc{ID: id}
+	var lxdAddress string
+	if err := target.getLxc(s.DB); err == nil {
+		lxdInstance := lxd{ID: target.LxdID}
+		if err := lxdInstance.getLxd(s.DB); err == nil {
+			lxdAddress = lxdInstance.Address
+		}
+	}
+
+	return []saga.Step{
+		{Name: "reserve-lxd-slot"},
+		{
+			Name: "insert-lxc-row",
+			Compensate: func(ctx context.Context) error {
+				return target.deleteLxc(s.DB)
+			},
+		},
+		{
+			Name: "call-agent",
+			Compensate: func(ctx context.Context) error {
+				if lxdAddress == "" {
+					return nil
+				}
+				return s.deleteNewLxc(target.Name, lxdAddress)
+			},
+		},
+		{Name: "insert-operation"},
+	}
+}
+
+// recoverCreateVMSteps is the VM analogue of recoverCreateLxcSteps.
+func (s *scheduler) recoverCreateVMSteps(id string) []saga.Step {
+	target := vm{ID: id}
+	var lxdAddress string
+	if err := target.getVM(s.DB); err == nil {
+		lxdInstance := lxd{ID: target.LxdID}
+		if err := lxdInstance.getLxd(s.DB); err == nil {
+			lxdAddress = lxdInstance.Address
+		}
+	}
+
+	return []saga.Step{
+		{Name: "reserve-lxd-slot"},
+		{
+			Name: "insert-vm-row",
+			Compensate: func(ctx context.Context) error {
+				return target.deleteVM(s.DB)
+			},
+		},
+		{
+			Name: "call-agent",
+			Compensate: func(ctx context.Context) error {
+				if lxdAddress == "" {
+					return nil
+				}
+				return s.deleteNewVM(target.Name, lxdAddress)
+			},
+		},
+		{Name: "insert-operation"},
+	}
+}
+
 func (s *scheduler) run(port string) {
 	log.Fatal(http.ListenAndServe(port, s.Router))
 }
@@ -118,61 +396,115 @@ func (s *scheduler) createNewLxcHandler(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
 	defer r.Body.Close()
-	lxdInstance, err := s.metricsDB.getLowestLoadLxdInstance()
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
 
-	err = lxdInstance.getLxdByIP(s.DB)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
-
-	newLxc := lxc{
-		ID:         uuid.New(),
-		LxdID:      lxdInstance.ID,
-		Name:       data.Name,
-		Type:       data.Type,
-		Alias:      data.Alias,
-		IsDeployed: 1,
-	}
-
-	err = newLxc.insertLxc(s.DB)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
+	s.createNewLxcWithData(w, r, data)
+}
 
-	op, err := s.createNewLxc(data, lxdInstance.Address)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
+// createNewLxcWithData runs the container-creation saga for an
+// already-decoded request body, so callers that have already consumed
+// r.Body (e.g. createNewInstanceHandler) don't need to re-decode it.
+func (s *scheduler) createNewLxcWithData(w http.ResponseWriter, r *http.Request, data createContainerRequestData) {
+	var lxdInstance lxd
+	newLxc := lxc{ID: uuid.New()}
+	var op *operation
 
-	op.LxcID = newLxc.ID
-	err = op.insertOperation(s.DB)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
+	asyncOp := s.operations.Create(operations.ClassTask)
+	opCtx, cancel := detachedOperationContext(r)
+
+	createSaga := saga.New(newLxc.ID, "create-lxc", []saga.Step{
+		{
+			Name: "reserve-lxd-slot",
+			Forward: func(ctx context.Context) error {
+				instance, err := s.metricsDB.getLowestLoadLxdInstance()
+				if err != nil {
+					return err
+				}
+				if err := instance.getLxdByIP(s.DB); err != nil {
+					return err
+				}
+				lxdInstance = instance
+				return nil
+			},
+		},
+		{
+			Name: "insert-lxc-row",
+			Forward: func(ctx context.Context) error {
+				newLxc.LxdID = lxdInstance.ID
+				newLxc.Name = data.Name
+				newLxc.Type = data.Type
+				newLxc.Alias = data.Alias
+				newLxc.IsDeployed = 1
+				return newLxc.insertLxc(s.DB)
+			},
+			Compensate: func(ctx context.Context) error {
+				return newLxc.deleteLxc(s.DB)
+			},
+		},
+		{
+			Name: "call-agent",
+			Forward: func(ctx context.Context) error {
+				result, err := s.createNewLxc(ctx, data, lxdInstance.Address)
+				if err != nil {
+					return err
+				}
+				op = result
+				asyncOp.SetCancel(func() error {
+					cancelCtx, cancel := context.WithTimeout(context.Background(), defaultAgentTimeout)
+					defer cancel()
+					return s.getAgent().CancelOperation(cancelCtx, lxdInstance.Address, op.ID)
+				})
+				return nil
+			},
+			Compensate: func(ctx context.Context) error {
+				return s.deleteNewLxc(data.Name, lxdInstance.Address)
+			},
+		},
+		{
+			Name: "insert-operation",
+			Forward: func(ctx context.Context) error {
+				op.LxcID = newLxc.ID
+				return op.insertOperation(s.DB)
+			},
+		},
+	}, s.DB)
+
+	s.operations.Run(opCtx, asyncOp, func(ctx context.Context) (map[string]interface{}, error) {
+		defer cancel()
+		if err := createSaga.Run(ctx); err != nil {
+			return nil, err
+		}
+		s.events.Send(events.New(events.TypeLifecycle, events.ActionLxcCreated, map[string]interface{}{"lxc_id": newLxc.ID}))
+		return map[string]interface{}{"lxc_id": newLxc.ID, "operation": op}, nil
+	})
 
-	respondWithJSON(w, http.StatusOK, op)
-	return
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/operations/%s", asyncOp.ID))
+	respondWithJSON(w, http.StatusAccepted, asyncOp)
 }
 
-func (s *scheduler) createNewLxc(data createContainerRequestData, lxdIPAddress string) (op *operation, err error) {
-	url := fmt.Sprintf("http://%s:9200/api/v1/container", lxdIPAddress)
-	payload, err := json.Marshal(data)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+func (s *scheduler) createNewLxc(ctx context.Context, data createContainerRequestData, lxdIPAddress string) (*operation, error) {
+	remote, err := s.getAgent().CreateContainer(ctx, lxdIPAddress, data)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.client.executeOperationRequest(req)
+	return toLocalOperation(remote)
+}
+
+// deleteNewLxc issues a best-effort DELETE to the agent for a container that
+// was never fully committed. It is used as the compensating action for the
+// "call-agent" step of createNewLxcHandler's saga, which logs any error it
+// returns and carries on compensating. It always runs with its own background context so
+// the abort still goes out even if the saga's own context has already been
+// cancelled.
+func (s *scheduler) deleteNewLxc(name, lxdIPAddress string) error {
+	abortCtx, abortCancel := context.WithTimeout(context.Background(), defaultAgentTimeout)
+	defer abortCancel()
+
+	_, err := s.getAgent().DeleteContainer(abortCtx, lxdIPAddress, struct {
+		Name string `json:"name"`
+	}{Name: name})
+	return err
 }
 
 func (s *scheduler) deleteLxcHandler(w http.ResponseWriter, r *http.Request) {
@@ -182,55 +514,60 @@ func (s *scheduler) deleteLxcHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var data deleteLxcRequest
-
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&data); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	lxc := lxc{
-		ID: data.ID,
-	}
-
-	if err := lxc.getLxc(s.DB); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
-
-	lxd := lxd{
-		ID: lxc.LxdID,
-	}
-
-	if err := lxd.getLxd(s.DB); err != nil {
+	target := lxc{ID: data.ID}
+	if err := target.getLxc(s.DB); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	data.Name = lxc.Name
-
-	url := fmt.Sprintf("http://%s:9200/api/v1/container", lxd.Address)
-	payload, err := json.Marshal(data)
-	req, err := http.NewRequest("DELETE", url, bytes.NewBuffer(payload))
-	if err != nil {
+	lxdInstance := lxd{ID: target.LxdID}
+	if err := lxdInstance.getLxd(s.DB); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	op, err := s.client.executeOperationRequest(req)
-
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
-
-	err = lxc.deleteLxc(s.DB)
+	data.Name = target.Name
+	var op *operation
 
-	if err != nil {
+	opCtx, cancel := operationContext(r)
+	defer cancel()
+
+	deleteSaga := saga.New(target.ID, "delete-lxc", []saga.Step{
+		{
+			Name: "call-agent",
+			Forward: func(ctx context.Context) error {
+				remote, err := s.getAgent().DeleteContainer(ctx, lxdInstance.Address, data)
+				if err != nil {
+					return err
+				}
+				result, err := toLocalOperation(remote)
+				if err != nil {
+					return err
+				}
+				op = result
+				return nil
+			},
+		},
+		{
+			Name: "delete-lxc-row",
+			Forward: func(ctx context.Context) error {
+				return target.deleteLxc(s.DB)
+			},
+		},
+	}, s.DB)
+
+	if err := deleteSaga.Run(opCtx); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	s.events.Send(events.New(events.TypeLifecycle, events.ActionLxcDeleted, map[string]interface{}{"lxc_id": target.ID}))
 	respondWithJSON(w, http.StatusOK, op)
 }
 
@@ -252,40 +589,82 @@ func (s *scheduler) updateStateLxcHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	lxc := lxc{
-		ID: data.ID,
+	target := lxc{ID: data.ID}
+	if err := target.getLxc(s.DB); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	if err := lxc.getLxc(s.DB); err != nil {
+	lxdInstance := lxd{ID: target.LxdID}
+	if err := lxdInstance.getLxd(s.DB); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	lxd := lxd{
-		ID: lxc.LxdID,
-	}
+	var op *operation
 
-	if err := lxd.getLxd(s.DB); err != nil {
+	opCtx, cancel := operationContext(r)
+	defer cancel()
+
+	updateStateSaga := saga.New(target.ID, "update-state-lxc", []saga.Step{
+		{
+			Name: "call-agent",
+			Forward: func(ctx context.Context) error {
+				remote, err := s.getAgent().UpdateContainerState(ctx, lxdInstance.Address, data)
+				if err != nil {
+					return err
+				}
+				result, err := toLocalOperation(remote)
+				if err != nil {
+					return err
+				}
+				op = result
+				return nil
+			},
+		},
+	}, s.DB)
+
+	if err := updateStateSaga.Run(opCtx); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	url := fmt.Sprintf("http://%s:9200/api/v1/container/updatestate", lxd.Address)
-	payload, err := json.Marshal(data)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+	s.events.Send(events.New(events.TypeLifecycle, events.ActionStateChanged, map[string]interface{}{"lxc_id": target.ID, "state_action": data.State.Action}))
+	respondWithJSON(w, http.StatusOK, op)
+}
+
+func (s *scheduler) listOperationsHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, s.operations.List())
+}
+
+func (s *scheduler) waitOperationHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	timeout := 30 * time.Second
+	if raw := r.URL.Query().Get("timeout"); raw != "" {
+		if seconds, err := strconv.Atoi(raw); err == nil {
+			timeout = time.Duration(seconds) * time.Second
+		}
+	}
+
+	op, err := s.operations.Wait(r.Context(), id, timeout)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusNotFound, err.Error())
 		return
 	}
 
-	op, err := s.client.executeOperationRequest(req)
+	respondWithJSON(w, http.StatusOK, op)
+}
 
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+func (s *scheduler) cancelOperationHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if err := s.operations.Cancel(id); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, op)
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "cancelled"})
 }
 
 func respondWithError(w http.ResponseWriter, code int, message string) {